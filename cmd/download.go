@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -12,9 +15,13 @@ import (
 
 	youtube "github.com/kkdai/youtube/v2"
 	"github.com/spf13/cobra"
+	"github.com/vbauerster/mpb/v7"
 
 	// Import your custom client
 	newclient "github.com/AD0791/GoYoutube/cmd/newclient"
+	"github.com/AD0791/GoYoutube/cmd/newclient/formatspec"
+	sinkpkg "github.com/AD0791/GoYoutube/cmd/sink"
+	"github.com/AD0791/GoYoutube/cmd/transport"
 )
 
 var (
@@ -22,6 +29,18 @@ var (
 	outputDir    string
 	concurrency  int
 	skipExisting bool
+	formatSpec   string
+	formatSort   string
+	container    string
+	minRes       int
+	numChunks    int
+	writeInfo    bool
+	writeThumb   bool
+	embedThumb   bool
+	s3PartSize   int64
+	s3Region     string
+	proxiesFile  string
+	rps          float64
 )
 
 // downloadCmd is our Cobra subcommand ("GoYoutube download ...")
@@ -43,9 +62,21 @@ func init() {
 
 	// Register flags
 	downloadCmd.Flags().StringVarP(&inputURL, "url", "u", "", "YouTube URL (video or playlist) [required]")
-	downloadCmd.Flags().StringVarP(&outputDir, "out", "o", "./downloads", "Output directory")
+	downloadCmd.Flags().StringVarP(&outputDir, "out", "o", "./downloads", `Output directory, or an "s3://bucket/prefix/" target to stream straight into S3`)
 	downloadCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 4, "Number of parallel downloads")
 	downloadCmd.Flags().BoolVar(&skipExisting, "skip-existing", false, "Skip if output file already exists")
+	downloadCmd.Flags().StringVar(&formatSpec, "format", "best", `Format selection. Either a shorthand ("best", "bestvideo+bestaudio", "worst", an itag pair like "137+140") or a yt-dlp-style expression in the newclient/formatspec mini-language, e.g. "bv*[height<=1080][vcodec^=avc1]+ba[acodec=opus]/b"`)
+	downloadCmd.Flags().StringVar(&formatSort, "format-sort", "res,fps,tbr", `Comma-separated tie-break order for "bv*"/"ba"/etc selectors in --format: any of res,fps,tbr,abr`)
+	downloadCmd.Flags().StringVar(&container, "container", "mp4", "Output container when muxing separate streams (mp4 or mkv)")
+	downloadCmd.Flags().IntVar(&minRes, "min-resolution", 0, "Minimum resolution (e.g. 1080) that forces an adaptive video+audio merge instead of progressive")
+	downloadCmd.Flags().IntVar(&numChunks, "chunks", 8, "Number of concurrent Range requests per stream")
+	downloadCmd.Flags().BoolVar(&writeInfo, "write-info-json", false, "Write a .info.json sidecar with video metadata, selected format, and parsed chapters")
+	downloadCmd.Flags().BoolVar(&writeThumb, "write-thumbnail", false, "Download the highest-resolution thumbnail as a sidecar .jpg")
+	downloadCmd.Flags().BoolVar(&embedThumb, "embed-thumbnail", false, "Embed the downloaded thumbnail into the output MP4 as cover art (implies --write-thumbnail)")
+	downloadCmd.Flags().Int64Var(&s3PartSize, "s3-part-size", 5*1024*1024, "Multipart upload part size in bytes when --out is s3://...")
+	downloadCmd.Flags().StringVar(&s3Region, "s3-region", "", "AWS region for --out s3://... (defaults to the standard AWS SDK resolution chain)")
+	downloadCmd.Flags().StringVar(&proxiesFile, "proxies", "", "Path to a newline-separated list of SOCKS5/HTTP proxies to rotate through (defaults to $GOYT_PROXIES)")
+	downloadCmd.Flags().Float64Var(&rps, "rps", 0, "Requests/sec rate limit per destination host (googlevideo.com, youtube.com); 0 disables limiting")
 
 	// Mark the url as required
 	downloadCmd.MarkFlagRequired("url")
@@ -53,9 +84,16 @@ func init() {
 
 // Example function to demonstrate usage of newclient.MyClient
 func runDownload() error {
-	// 1) Create your extended client
+	// 1) Create your extended client. A transport.RoundTripper handles proxy
+	// rotation and per-host rate limiting for both the embedded
+	// youtube.Client calls (GetVideo, GetStream, ...) and the bare InnerTube
+	// POST in newclient.GetPlaylistPageToken - both read mc.HTTPClient.
+	rt, err := transport.New(proxiesFile, rps)
+	if err != nil {
+		return fmt.Errorf("transport.New failed: %w", err)
+	}
 	mc := &newclient.MyClient{
-		Client: &youtube.Client{}, // the original client
+		Client: &youtube.Client{HTTPClient: &http.Client{Transport: rt}},
 	}
 
 	// 2) Check if it’s a playlist
@@ -73,7 +111,11 @@ func runDownload() error {
 		}
 		log.Printf("Found %d items in %q\n", len(entries), playlist.Title)
 
-		// 4) Download them in parallel
+		// 4) Download them in parallel, with an overall progress bar for the
+		// playlist and a byte-progress bar per in-flight video.
+		p := newProgressContainer()
+		overall := addOverallBar(p, len(entries))
+
 		ch := make(chan youtube.PlaylistEntry, len(entries))
 		for _, e := range entries {
 			ch <- e
@@ -88,6 +130,7 @@ func runDownload() error {
 				for item := range ch {
 					if item.ID == "" {
 						log.Printf("[worker %d] Skipping missing ID\n", workerID)
+						overall.Increment()
 						continue
 					}
 					// Build watch URL => "https://www.youtube.com/watch?v=" + item.ID
@@ -95,32 +138,38 @@ func runDownload() error {
 					// Build output file path
 					safePlaylist := sanitizeFilename(playlist.Title)
 					safeVideo := sanitizeFilename(item.Title)
-					outFile := filepath.Join(outputDir, safePlaylist, safeVideo+".mp4")
+					outFile := joinOutputPath(outputDir, safePlaylist, safeVideo+".mp4")
 
-					if skipExisting && fileExists(outFile) {
+					if skipExisting && outputExists(outFile) {
 						log.Printf("[worker %d] Skipping %s (exists)\n", workerID, outFile)
+						overall.Increment()
 						continue
 					}
 
 					log.Printf("[worker %d] Downloading: %s => %s\n", workerID, item.Title, outFile)
-					if err := downloadSingleVideo(mc.Client, videoURL, outFile); err != nil {
+					if err := downloadSingleVideo(mc.Client, videoURL, outFile, p, safeVideo); err != nil {
 						log.Printf("[worker %d] Error: %v\n", workerID, err)
 					}
+					overall.Increment()
 				}
 			}(i + 1)
 		}
 		wg.Wait()
+		p.Wait()
 
 		log.Println("Done with playlist downloads.")
 	} else {
 		// Single video path
-		outFile := filepath.Join(outputDir, "output.mp4")
-		if skipExisting && fileExists(outFile) {
+		outFile := joinOutputPath(outputDir, "output.mp4")
+		if skipExisting && outputExists(outFile) {
 			log.Printf("Skipping %s (already exists)\n", outFile)
 			return nil
 		}
 
-		if err := downloadSingleVideo(mc.Client, inputURL, outFile); err != nil {
+		p := newProgressContainer()
+		err := downloadSingleVideo(mc.Client, inputURL, outFile, p, "video")
+		p.Wait()
+		if err != nil {
 			return fmt.Errorf("error downloading single video: %w", err)
 		}
 	}
@@ -148,59 +197,284 @@ func fetchAllPlaylistEntries(mc *newclient.MyClient, playlistID string) ([]youtu
 	return all, nil
 }
 
-// downloadSingleVideo handles a single video. If you previously used f.Resolution,
-// swap to f.QualityLabel (which might be "1080p", etc.).
-func downloadSingleVideo(c *youtube.Client, videoURL, outFile string) error {
+// downloadSingleVideo handles a single video. --format is resolved via
+// resolveDownloadFormats, which yields either one progressive format (video
+// and audio in the same stream) downloaded directly here, or a video-only +
+// audio-only pair handed off to downloadAdaptive to mux with ffmpeg.
+func downloadSingleVideo(c *youtube.Client, videoURL, outFile string, p *mpb.Progress, label string) error {
 	vid, err := c.GetVideo(videoURL)
 	if err != nil {
 		return fmt.Errorf("GetVideo failed: %w", err)
 	}
 
-	// Pick a progressive format with the highest QualityLabel + audioChannels > 0
-	var best *youtube.Format
-	for i := range vid.Formats {
-		f := &vid.Formats[i]
-		// e.g. f.QualityLabel => "1080p", "720p", "480p", etc.
-		// check if there's audio
-		if f.AudioChannels > 0 && f.QualityLabel != "" {
-			if best == nil || parseRes(f.QualityLabel) > parseRes(best.QualityLabel) {
-				best = f
-			}
+	formats, err := resolveDownloadFormats(vid, formatSpec, minRes)
+	if err != nil {
+		return fmt.Errorf("resolving --format %q: %w", formatSpec, err)
+	}
+	if len(formats) == 2 {
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			return fmt.Errorf("ffmpeg not found on PATH: required to mux itag %d+%d for --format=%q (install ffmpeg or pick a progressive format)", formats[0].ItagNo, formats[1].ItagNo, formatSpec)
 		}
+		return downloadAdaptive(c, vid, formats[0], formats[1], outFile, p, label)
 	}
 
-	if best == nil {
-		return fmt.Errorf("no progressive format with audio found (need separate audio/video merge?)")
+	best := formats[0]
+	streamURL, err := c.GetStreamURL(vid, best)
+	if err != nil {
+		return fmt.Errorf("GetStreamURL failed: %w", err)
 	}
 
-	stream, total, err := c.GetStream(vid, best)
+	dst, err := newOutputSink(outFile, best.ContentLength)
 	if err != nil {
-		return fmt.Errorf("GetStream failed: %w", err)
+		return fmt.Errorf("opening output %q: %w", outFile, err)
 	}
-	defer stream.Close()
 
-	if err := os.MkdirAll(filepath.Dir(outFile), 0o755); err != nil {
-		return err
+	bar := addVideoBar(p, label, best.ContentLength)
+	if err := rangedDownload(httpClientFor(c), streamURL, dst, best.ContentLength, numChunks, bar); err != nil {
+		sinkpkg.Discard(dst)
+		return fmt.Errorf("ranged download failed: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("finalizing output %q: %w", outFile, err)
 	}
-	file, err := os.Create(outFile)
+	log.Printf("Downloaded %s => %d bytes\n", outFile, best.ContentLength)
+
+	if !sinkpkg.IsS3URL(outFile) {
+		writeSidecars(vid, best, outFile, httpClientFor(c))
+	}
+	return nil
+}
+
+// downloadAdaptive downloads a video-only and an audio-only DASH format
+// (resolved by resolveDownloadFormats) to temp files and muxes them into
+// outFile with ffmpeg -c copy (no re-encoding).
+func downloadAdaptive(c *youtube.Client, vid *youtube.Video, videoFmt, audioFmt *youtube.Format, outFile string, p *mpb.Progress, label string) error {
+	tmpVideo, err := downloadToTemp(c, vid, videoFmt, "goyt-video-*.tmp", p, label+" [video]")
 	if err != nil {
-		return err
+		return fmt.Errorf("downloading video stream: %w", err)
 	}
-	defer file.Close()
+	defer os.Remove(tmpVideo)
 
-	written, err := io.Copy(file, stream)
+	tmpAudio, err := downloadToTemp(c, vid, audioFmt, "goyt-audio-*.tmp", p, label+" [audio]")
 	if err != nil {
-		return err
+		os.Remove(tmpVideo)
+		return fmt.Errorf("downloading audio stream: %w", err)
+	}
+	defer os.Remove(tmpAudio)
+
+	ext := container
+	if ext == "" {
+		ext = "mp4"
+	}
+
+	// ffmpeg needs a real path to mux into; when outFile is an s3:// target
+	// mux locally first, then stream the muxed file up to S3 and remove it.
+	s3Target := sinkpkg.IsS3URL(outFile)
+	muxedFile := strings.TrimSuffix(outFile, filepath.Ext(outFile)) + "." + ext
+	if s3Target {
+		tmp, err := os.CreateTemp("", "goyt-mux-*."+ext)
+		if err != nil {
+			return err
+		}
+		muxedFile = tmp.Name()
+		tmp.Close()
+		defer os.Remove(muxedFile)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(muxedFile), 0o755); err != nil {
+			return err
+		}
+	}
+
+	if err := muxWithFFmpeg(tmpVideo, tmpAudio, muxedFile); err != nil {
+		return fmt.Errorf("ffmpeg mux failed: %w", err)
+	}
+
+	if s3Target {
+		if err := uploadLocalFileToS3(muxedFile, outFile); err != nil {
+			return fmt.Errorf("uploading muxed file to %s: %w", outFile, err)
+		}
+	} else {
+		outFile = muxedFile
+	}
+
+	log.Printf("Downloaded (adaptive itag %d+%d) => %s\n", videoFmt.ItagNo, audioFmt.ItagNo, outFile)
+
+	if !s3Target {
+		writeSidecars(vid, videoFmt, outFile, httpClientFor(c))
 	}
-	log.Printf("Downloaded %s => %d bytes (expected ~%d)\n", outFile, written, total)
 	return nil
 }
 
-// parseRes turns "1080p" into 1080, ignoring any suffix
-func parseRes(label string) int {
-	label = strings.TrimSuffix(label, "p")
-	val, _ := strconv.Atoi(label)
-	return val
+// writeSidecars runs the optional --write-info-json/--write-thumbnail/
+// --embed-thumbnail steps after a successful download. Failures here are
+// logged rather than returned: the media itself already landed, and a
+// missing thumbnail shouldn't fail the whole download. httpClient is the
+// same rate-limited/proxied client the video itself was fetched with, so the
+// thumbnail request doesn't bypass --proxies/--rps.
+func writeSidecars(vid *youtube.Video, f *youtube.Format, outFile string, httpClient *http.Client) {
+	if writeInfo {
+		if err := writeInfoJSON(vid, f, outFile); err != nil {
+			log.Printf("write-info-json failed for %s: %v", outFile, err)
+		} else if chapters := parseChapters(vid.Description, vid.Duration); len(chapters) > 0 {
+			if err := writeChaptersSRT(chapters, vid.Duration, outFile); err != nil {
+				log.Printf("write chapters failed for %s: %v", outFile, err)
+			}
+		}
+	}
+
+	if writeThumb || embedThumb {
+		thumbPath, err := downloadThumbnail(vid.ID, outFile, httpClient)
+		if err != nil {
+			log.Printf("write-thumbnail failed for %s: %v", outFile, err)
+			return
+		}
+		if embedThumb {
+			if err := embedThumbnail(outFile, thumbPath); err != nil {
+				log.Printf("embed-thumbnail failed for %s: %v", outFile, err)
+			}
+		}
+	}
+}
+
+// resolveDownloadFormats translates spec (a legacy shorthand or a
+// newclient/formatspec expression) into one progressive format or a
+// video-only+audio-only pair drawn from vid.Formats. When minRes > 0 it's
+// injected as a "height>=minRes" filter on any bare "b"/"w" alternative, so a
+// progressive stream below the threshold is skipped in favor of the next
+// alternative (e.g. an adaptive "bv*+ba" fallback) rather than returned as-is.
+func resolveDownloadFormats(vid *youtube.Video, spec string, minRes int) ([]*youtube.Format, error) {
+	parsed, err := formatspec.Parse(translateLegacySpec(spec))
+	if err != nil {
+		return nil, err
+	}
+	injectMinHeight(parsed, minRes)
+
+	byItag := make(map[int]*youtube.Format, len(vid.Formats))
+	specFormats := make([]formatspec.Format, 0, len(vid.Formats))
+	for i := range vid.Formats {
+		f := &vid.Formats[i]
+		byItag[f.ItagNo] = f
+		specFormats = append(specFormats, formatAttrsOf(*f))
+	}
+
+	var sortKeys []string
+	if formatSort != "" {
+		sortKeys = strings.Split(formatSort, ",")
+	}
+
+	chosen, err := formatspec.Resolve(parsed, specFormats, sortKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*youtube.Format, 0, len(chosen))
+	for _, c := range chosen {
+		f, ok := byItag[c.Itag]
+		if !ok {
+			return nil, fmt.Errorf("resolved itag %d not found in video formats", c.Itag)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// translateLegacySpec maps the original hardcoded --format shorthands onto
+// equivalent formatspec expressions; anything else (an itag pair like
+// "137+140", or a formatspec expression already) passes through unchanged.
+func translateLegacySpec(spec string) string {
+	switch spec {
+	case "best":
+		return "b/bv*+ba"
+	case "worst":
+		return "w/wv+wa"
+	case "bestvideo+bestaudio":
+		return "bv*+ba"
+	default:
+		return spec
+	}
+}
+
+// injectMinHeight appends a "height>=minRes" filter to every single-selector
+// "b"/"w" alternative in spec, in place, so --min-resolution still forces a
+// fallback away from an under-resolution progressive stream.
+func injectMinHeight(spec *formatspec.Spec, minRes int) {
+	if minRes <= 0 {
+		return
+	}
+	for i := range spec.Alternatives {
+		alt := &spec.Alternatives[i]
+		if len(alt.Selectors) != 1 {
+			continue
+		}
+		sel := &alt.Selectors[0]
+		if sel.Kind != "b" && sel.Kind != "w" {
+			continue
+		}
+		sel.Filters = append(sel.Filters, formatspec.Filter{Attr: "height", Op: ">=", Value: strconv.Itoa(minRes)})
+	}
+}
+
+// downloadToTemp streams format f for vid into a temp file in os.TempDir and
+// returns its path. The caller is responsible for removing it.
+func downloadToTemp(c *youtube.Client, vid *youtube.Video, f *youtube.Format, pattern string, p *mpb.Progress, label string) (string, error) {
+	streamURL, err := c.GetStreamURL(vid, f)
+	if err != nil {
+		return "", fmt.Errorf("GetStreamURL failed: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	dst, err := sinkpkg.NewFileSink(tmpPath, f.ContentLength)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	bar := addVideoBar(p, label, f.ContentLength)
+	if err := rangedDownload(httpClientFor(c), streamURL, dst, f.ContentLength, numChunks, bar); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// httpClientFor returns the client's configured HTTPClient, falling back to
+// http.DefaultClient the same way newclient.GetPlaylistPageToken does.
+func httpClientFor(c *youtube.Client) *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// muxWithFFmpeg combines a video-only and audio-only file into outFile without
+// re-encoding. The container is whatever extension outFile already has (mp4
+// by default, or --container's choice) - there's no automatic fallback to a
+// different container on a codec mismatch, so a vp9/opus pair muxed into
+// .mp4 will produce a file some mp4 players choke on; pass --container mkv
+// up front if the source format needs it.
+func muxWithFFmpeg(videoPath, audioPath, outFile string) error {
+	cmd := exec.Command("ffmpeg", "-y",
+		"-i", videoPath,
+		"-i", audioPath,
+		"-c", "copy",
+		outFile,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
 }
 
 func isPlaylist(u string) bool {
@@ -220,3 +494,96 @@ func fileExists(path string) bool {
 	}
 	return !info.IsDir()
 }
+
+// outputExists backs --skip-existing for either target kind: a HeadObject
+// against S3, or the plain os.Stat used for local files.
+func outputExists(outFile string) bool {
+	if !sinkpkg.IsS3URL(outFile) {
+		return fileExists(outFile)
+	}
+	bucket, key, err := sinkpkg.ParseS3URL(outFile)
+	if err != nil {
+		return false
+	}
+	client, err := sinkpkg.NewS3Client(context.Background(), s3Region)
+	if err != nil {
+		return false
+	}
+	return sinkpkg.Exists(context.Background(), client, bucket, key)
+}
+
+// joinOutputPath joins base with parts the way filepath.Join does for local
+// directories, but preserves the "s3://" scheme instead of collapsing its
+// double slash the way filepath.Join would.
+func joinOutputPath(base string, parts ...string) string {
+	if sinkpkg.IsS3URL(base) {
+		segments := append([]string{strings.TrimSuffix(base, "/")}, parts...)
+		return strings.Join(segments, "/")
+	}
+	return filepath.Join(append([]string{base}, parts...)...)
+}
+
+// newOutputSink opens outFile for writing, as a local FileSink or an S3Sink
+// depending on its scheme.
+func newOutputSink(outFile string, size int64) (sinkpkg.Sink, error) {
+	if sinkpkg.IsS3URL(outFile) {
+		bucket, key, err := sinkpkg.ParseS3URL(outFile)
+		if err != nil {
+			return nil, err
+		}
+		return sinkpkg.NewS3Sink(context.Background(), bucket, key, s3PartSize, size, s3Region)
+	}
+	if err := os.MkdirAll(filepath.Dir(outFile), 0o755); err != nil {
+		return nil, err
+	}
+	return sinkpkg.NewFileSink(outFile, size)
+}
+
+// uploadLocalFileToS3 streams localPath into s3URL via the same S3Sink used
+// for direct downloads, for paths (like the ffmpeg mux output) that have to
+// land on local disk first.
+func uploadLocalFileToS3(localPath, s3URL string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dst, err := newOutputSink(s3URL, info.Size())
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, s3PartSizeOrDefault())
+	var offset int64
+	for {
+		n, readErr := f.Read(buf)
+		if n > 0 {
+			if _, err := dst.WriteAt(buf[:n], offset); err != nil {
+				sinkpkg.Discard(dst)
+				return err
+			}
+			offset += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			sinkpkg.Discard(dst)
+			return readErr
+		}
+	}
+	return dst.Close()
+}
+
+func s3PartSizeOrDefault() int64 {
+	if s3PartSize > 0 {
+		return s3PartSize
+	}
+	return 5 * 1024 * 1024
+}
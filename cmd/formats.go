@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	youtube "github.com/kkdai/youtube/v2"
+	"github.com/spf13/cobra"
+
+	newclient "github.com/AD0791/GoYoutube/cmd/newclient"
+	"github.com/AD0791/GoYoutube/cmd/newclient/formatspec"
+)
+
+var formatsVerbose bool
+
+// formatsCmd is our Cobra subcommand ("GoYoutube formats ...")
+var formatsCmd = &cobra.Command{
+	Use:   "formats",
+	Short: "List every available format for a YouTube URL",
+	Long: `Print a table of every youtube.Format available for a video: itag, mime,
+codec, resolution, fps, bitrate, size, audio channels, audio sample rate,
+HDR flag, and whether it carries audio/video. Useful for picking an itag
+pair or writing a --format expression for "download".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFormats()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(formatsCmd)
+
+	formatsCmd.Flags().StringVarP(&inputURL, "url", "u", "", "YouTube URL [required]")
+	formatsCmd.Flags().BoolVarP(&formatsVerbose, "verbose", "v", false, "Also print each format's stream URL")
+
+	formatsCmd.MarkFlagRequired("url")
+}
+
+func runFormats() error {
+	mc := &newclient.MyClient{Client: &youtube.Client{}}
+
+	vid, err := mc.GetVideo(inputURL)
+	if err != nil {
+		return fmt.Errorf("GetVideo failed: %w", err)
+	}
+
+	fmt.Printf("%-6s %-28s %-8s %-10s %4s %9s %9s %3s %6s %4s %5s %5s\n",
+		"itag", "mime", "codec", "resolution", "fps", "bitrate", "size", "hdr", "achan", "asr", "audio", "video")
+
+	for _, f := range vid.Formats {
+		attrs := formatAttrsOf(f)
+		codec := vcodecOf(f.MimeType)
+		if attrs.ACodec != "" {
+			codec = attrs.ACodec
+		}
+		fmt.Printf("%-6d %-28s %-8s %-10s %4d %9d %9d %3s %6d %4s %5t %5t\n",
+			f.ItagNo, f.MimeType, codec, f.QualityLabel, f.FPS, f.Bitrate, f.ContentLength,
+			hdrFlag(f), f.AudioChannels, f.AudioSampleRate, attrs.HasAudio, attrs.HasVideo)
+
+		if formatsVerbose {
+			fmt.Printf("       url: %s\n", f.URL)
+		}
+	}
+	return nil
+}
+
+// hdrItags are the itags YouTube has historically assigned to HDR (HLG)
+// variants of its VP9/AV1 adaptive formats; youtube.Format carries no HDR/
+// color-space field of its own, so this is the only signal available without
+// an extra network round trip.
+var hdrItags = map[int]bool{
+	330: true, 331: true, 332: true, 333: true, 334: true, 335: true, 336: true, 337: true,
+	694: true, 695: true, 696: true, 697: true, 698: true, 699: true,
+}
+
+func hdrFlag(f youtube.Format) string {
+	if hdrItags[f.ItagNo] {
+		return "yes"
+	}
+	return "no"
+}
+
+// vcodecOf extracts the codec token out of a MIME type like
+// `video/mp4; codecs="avc1.640028"` -> "avc1".
+func vcodecOf(mime string) string {
+	i := strings.Index(mime, "codecs=")
+	if i < 0 {
+		return ""
+	}
+	rest := strings.Trim(mime[i+len("codecs="):], `"`)
+	codec := strings.SplitN(rest, ",", 2)[0]
+	return strings.SplitN(codec, ".", 2)[0]
+}
+
+// formatAttrsOf translates a youtube.Format into formatspec.Format so the
+// mini-language parser never has to import the youtube package itself.
+func formatAttrsOf(f youtube.Format) formatspec.Format {
+	hasAudio := f.AudioChannels > 0
+	hasVideo := f.QualityLabel != "" || f.Width > 0 || f.Height > 0
+	codec := vcodecOf(f.MimeType)
+
+	attrs := formatspec.Format{
+		Itag:     f.ItagNo,
+		Height:   f.Height,
+		FPS:      f.FPS,
+		TBR:      f.Bitrate,
+		HasVideo: hasVideo,
+		HasAudio: hasAudio,
+	}
+	if hasVideo {
+		attrs.VCodec = codec
+	}
+	if hasAudio {
+		attrs.ACodec = codec
+		attrs.ABR = f.Bitrate
+	}
+	return attrs
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	youtube "github.com/kkdai/youtube/v2"
+)
+
+// infoJSONFormat is the subset of youtube.Format we mirror into the
+// .info.json sidecar - just enough for a downstream archiver to know what it
+// got without re-deriving it from the raw API response.
+type infoJSONFormat struct {
+	Itag       int    `json:"itag"`
+	MimeType   string `json:"mime_type"`
+	Bitrate    int    `json:"bitrate"`
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// infoJSON mirrors the handful of fields downstream archivers (ytsync,
+// yt-dlp) expect in a sidecar file next to the media.
+type infoJSON struct {
+	VideoID     string         `json:"video_id"`
+	Title       string         `json:"title"`
+	Author      string         `json:"author"`
+	Description string         `json:"description"`
+	PublishDate string         `json:"publish_date"`
+	Duration    float64        `json:"duration_seconds"`
+	Views       int            `json:"view_count"`
+	Format      infoJSONFormat `json:"format"`
+	Chapters    []VideoChapter `json:"chapters,omitempty"`
+}
+
+// VideoChapter is a single `0:00 Intro`-style timestamp parsed out of a
+// video description.
+type VideoChapter struct {
+	Start time.Duration `json:"start_seconds"`
+	Title string        `json:"title"`
+}
+
+// writeInfoJSON writes vid's metadata plus the chosen format and any
+// description-derived chapters to outFile+".info.json".
+func writeInfoJSON(vid *youtube.Video, f *youtube.Format, outFile string) error {
+	info := infoJSON{
+		VideoID:     vid.ID,
+		Title:       vid.Title,
+		Author:      vid.Author,
+		Description: vid.Description,
+		PublishDate: vid.PublishDate.Format("2006-01-02"),
+		Duration:    vid.Duration.Seconds(),
+		Views:       vid.Views,
+		Format: infoJSONFormat{
+			Itag:       f.ItagNo,
+			MimeType:   f.MimeType,
+			Bitrate:    f.Bitrate,
+			Resolution: f.QualityLabel,
+		},
+		Chapters: parseChapters(vid.Description, vid.Duration),
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal info.json: %w", err)
+	}
+
+	sidecar := sidecarPath(outFile, ".info.json")
+	return os.WriteFile(sidecar, data, 0o644)
+}
+
+// chapterLineRe matches description lines like "0:00 Intro" or "1:02:03 - Outro".
+var chapterLineRe = regexp.MustCompile(`(?m)^\s*(?:-\s*)?(\d{1,2}(?::\d{2}){1,2})\s*[-–:]?\s*(.+)$`)
+
+// parseChapters scans a video description for SponsorBlock-style timestamp
+// lines and returns them in chronological order. total bounds the last
+// chapter's implicit end and is otherwise unused here - callers needing
+// end times derive them from the next chapter's start.
+func parseChapters(description string, total time.Duration) []VideoChapter {
+	var chapters []VideoChapter
+	for _, line := range strings.Split(description, "\n") {
+		m := chapterLineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d, ok := parseTimestamp(m[1])
+		if !ok {
+			continue
+		}
+		title := strings.TrimSpace(m[2])
+		if title == "" {
+			continue
+		}
+		chapters = append(chapters, VideoChapter{Start: d, Title: title})
+	}
+	// A lone "0:00 Intro" isn't really chapter markup, it's a description line
+	// that happens to start with a number; require at least two hits.
+	if len(chapters) < 2 {
+		return nil
+	}
+	return chapters
+}
+
+// parseTimestamp turns "1:02:03", "12:34" or "0:05" into a duration.
+func parseTimestamp(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	var h, m, sec int
+	var err error
+	switch len(parts) {
+	case 3:
+		if h, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, false
+		}
+		if m, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, false
+		}
+		if sec, err = strconv.Atoi(parts[2]); err != nil {
+			return 0, false
+		}
+	case 2:
+		if m, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, false
+		}
+		if sec, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second, true
+}
+
+// writeChaptersSRT writes chapters as an SRT file next to outFile, using the
+// next chapter's start (or total) as each entry's end time.
+func writeChaptersSRT(chapters []VideoChapter, total time.Duration, outFile string) error {
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	for i, ch := range chapters {
+		end := total
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, srtTimestamp(ch.Start), srtTimestamp(end), ch.Title)
+	}
+
+	return os.WriteFile(sidecarPath(outFile, ".chapters.srt"), []byte(sb.String()), 0o644)
+}
+
+func srtTimestamp(d time.Duration) string {
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	ms := d / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+// thumbnailURLs returns the maxresdefault -> hqdefault fallback chain for vid.
+func thumbnailURLs(videoID string) []string {
+	base := "https://i.ytimg.com/vi/" + videoID + "/"
+	return []string{base + "maxresdefault.jpg", base + "hqdefault.jpg"}
+}
+
+// downloadThumbnail walks thumbnailURLs(videoID) and saves the first one that
+// returns 200 to outFile+".jpg". Returns the written path, or "" if none hit.
+// httpClient is the caller's rate-limited/proxied client; a nil httpClient
+// falls back to http.DefaultClient.
+func downloadThumbnail(videoID, outFile string, httpClient *http.Client) (string, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	dest := sidecarPath(outFile, ".jpg")
+	for _, url := range thumbnailURLs(videoID) {
+		resp, err := httpClient.Get(url)
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+
+		f, err := os.Create(dest)
+		if err != nil {
+			resp.Body.Close()
+			return "", err
+		}
+		_, copyErr := io.Copy(f, resp.Body)
+		resp.Body.Close()
+		f.Close()
+		if copyErr != nil {
+			os.Remove(dest)
+			return "", copyErr
+		}
+		return dest, nil
+	}
+	return "", fmt.Errorf("no thumbnail found for %s (tried maxresdefault, hqdefault)", videoID)
+}
+
+// embedThumbnail re-muxes outFile in place with thumbPath attached as the
+// MP4 cover art, via ffmpeg's attached_pic disposition.
+func embedThumbnail(outFile, thumbPath string) error {
+	tmp := outFile + ".embed.tmp" + filepath.Ext(outFile)
+	cmd := exec.Command("ffmpeg", "-y",
+		"-i", outFile,
+		"-i", thumbPath,
+		"-map", "0", "-map", "1",
+		"-c", "copy",
+		"-disposition:v:1", "attached_pic",
+		tmp,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ffmpeg embed-thumbnail failed: %w: %s", err, out)
+	}
+	return os.Rename(tmp, outFile)
+}
+
+// sidecarPath replaces outFile's extension with suffix, e.g.
+// "video.mp4" + ".info.json" -> "video.info.json".
+func sidecarPath(outFile, suffix string) string {
+	return strings.TrimSuffix(outFile, filepath.Ext(outFile)) + suffix
+}
@@ -0,0 +1,319 @@
+// Package formatspec implements the small format-selection expression
+// language behind `download --format`, e.g.
+// "bv*[height<=1080][vcodec^=avc1]+ba[acodec=opus]/b". It only knows about
+// the plain Format struct below, not youtube.Format or any network call, so
+// it can be unit-tested on its own.
+package formatspec
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is the subset of youtube.Format the expression language filters and
+// scores on. Callers translate youtube.Format into this before calling Resolve.
+type Format struct {
+	Itag     int
+	Height   int
+	FPS      int
+	VCodec   string // e.g. "avc1", "vp9", "" if video-only N/A
+	ACodec   string // e.g. "mp4a", "opus", "" if audio-only N/A
+	TBR      int    // total bitrate, bits/sec
+	ABR      int    // audio bitrate, bits/sec
+	HasVideo bool
+	HasAudio bool
+}
+
+// kind identifies which bucket of formats a selector draws from, and
+// whether ties should prefer the highest or lowest score.
+type kind struct {
+	name      string
+	worst     bool
+	video     bool // selector only matches formats with a video stream
+	audio     bool // selector only matches formats with an audio stream
+	videoOnly bool
+	audioOnly bool
+}
+
+var kinds = map[string]kind{
+	// "b"/"w": best/worst whole (progressive) stream - has both audio+video.
+	"b": {name: "b", video: true, audio: true},
+	"w": {name: "w", worst: true, video: true, audio: true},
+	// "bv*"/"wv*": best/worst video, progressive or video-only.
+	"bv*": {name: "bv*", video: true},
+	"wv*": {name: "wv*", worst: true, video: true},
+	// "bv"/"wv": best/worst video-ONLY stream.
+	"bv": {name: "bv", video: true, videoOnly: true},
+	"wv": {name: "wv", worst: true, video: true, videoOnly: true},
+	// "ba"/"wa": best/worst audio-ONLY stream.
+	"ba": {name: "ba", audio: true, audioOnly: true},
+	"wa": {name: "wa", worst: true, audio: true, audioOnly: true},
+}
+
+// Filter is one "[attr<op>value]" clause, e.g. "[height<=1080]".
+type Filter struct {
+	Attr  string
+	Op    string // "=", "^=", "<=", ">=", "<", ">"
+	Value string
+}
+
+// Selector is a single selection token, e.g. "bv*[height<=1080]" or a bare
+// itag like "137".
+type Selector struct {
+	Kind    string // key into `kinds`, or "" if Itag is set
+	Itag    int
+	Filters []Filter
+}
+
+// Alternative is a "+"-joined group of selectors that must ALL resolve for
+// the alternative to be usable, e.g. "bv*[height<=1080]+ba[acodec=opus]".
+type Alternative struct {
+	Selectors []Selector
+}
+
+// Spec is a "/"-separated list of Alternatives, tried in order until one
+// resolves against the available formats.
+type Spec struct {
+	Alternatives []Alternative
+}
+
+// Parse compiles a format-selection expression. It doesn't look at any
+// format list, so a syntactically valid spec always parses even if it can
+// never match anything.
+func Parse(expr string) (*Spec, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("empty format spec")
+	}
+
+	var spec Spec
+	for _, altStr := range strings.Split(expr, "/") {
+		altStr = strings.TrimSpace(altStr)
+		if altStr == "" {
+			continue
+		}
+		var alt Alternative
+		for _, selStr := range strings.Split(altStr, "+") {
+			sel, err := parseSelector(strings.TrimSpace(selStr))
+			if err != nil {
+				return nil, fmt.Errorf("format spec %q: %w", expr, err)
+			}
+			alt.Selectors = append(alt.Selectors, sel)
+		}
+		spec.Alternatives = append(spec.Alternatives, alt)
+	}
+	if len(spec.Alternatives) == 0 {
+		return nil, fmt.Errorf("format spec %q: no alternatives", expr)
+	}
+	return &spec, nil
+}
+
+func parseSelector(s string) (Selector, error) {
+	if s == "" {
+		return Selector{}, fmt.Errorf("empty selector")
+	}
+
+	name := s
+	var filterStr string
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		name = s[:i]
+		filterStr = s[i:]
+	}
+
+	var sel Selector
+	if itag, err := strconv.Atoi(name); err == nil {
+		sel.Itag = itag
+	} else if _, ok := kinds[name]; ok {
+		sel.Kind = name
+	} else {
+		return Selector{}, fmt.Errorf("unknown selector %q", name)
+	}
+
+	for filterStr != "" {
+		end := strings.IndexByte(filterStr, ']')
+		if !strings.HasPrefix(filterStr, "[") || end < 0 {
+			return Selector{}, fmt.Errorf("unterminated filter in %q", s)
+		}
+		clause := filterStr[1:end]
+		filterStr = filterStr[end+1:]
+
+		f, err := parseFilter(clause)
+		if err != nil {
+			return Selector{}, fmt.Errorf("selector %q: %w", s, err)
+		}
+		sel.Filters = append(sel.Filters, f)
+	}
+	return sel, nil
+}
+
+// ops is checked longest-first so "<=" isn't mistaken for "<".
+var ops = []string{"^=", "<=", ">=", "=", "<", ">"}
+
+func parseFilter(clause string) (Filter, error) {
+	for _, op := range ops {
+		if i := strings.Index(clause, op); i >= 0 {
+			return Filter{Attr: strings.TrimSpace(clause[:i]), Op: op, Value: strings.TrimSpace(clause[i+len(op):])}, nil
+		}
+	}
+	return Filter{}, fmt.Errorf("no operator in filter %q", clause)
+}
+
+// Resolve tries each Alternative in order and returns the first one whose
+// every selector matches at least one format in formats. sortKeys (e.g.
+// []string{"res","fps","tbr"}) breaks ties among a selector's candidates,
+// highest first (lowest first for a "w"-family selector); it defaults to
+// []string{"res", "fps", "tbr"} when empty.
+func Resolve(spec *Spec, formats []Format, sortKeys []string) ([]Format, error) {
+	if len(sortKeys) == 0 {
+		sortKeys = []string{"res", "fps", "tbr"}
+	}
+
+	for _, alt := range spec.Alternatives {
+		chosen := make([]Format, 0, len(alt.Selectors))
+		ok := true
+		for _, sel := range alt.Selectors {
+			f, found := resolveSelector(sel, formats, sortKeys)
+			if !found {
+				ok = false
+				break
+			}
+			chosen = append(chosen, f)
+		}
+		if ok {
+			return chosen, nil
+		}
+	}
+	return nil, fmt.Errorf("no alternative matched the available formats")
+}
+
+func resolveSelector(sel Selector, formats []Format, sortKeys []string) (Format, bool) {
+	if sel.Kind == "" {
+		for _, f := range formats {
+			if f.Itag == sel.Itag {
+				return f, true
+			}
+		}
+		return Format{}, false
+	}
+
+	k := kinds[sel.Kind]
+	var candidates []Format
+	for _, f := range formats {
+		if k.video && !f.HasVideo {
+			continue
+		}
+		if k.audio && !f.HasAudio {
+			continue
+		}
+		if k.videoOnly && f.HasAudio {
+			continue
+		}
+		if k.audioOnly && f.HasVideo {
+			continue
+		}
+		if !matchesFilters(f, sel.Filters) {
+			continue
+		}
+		candidates = append(candidates, f)
+	}
+	if len(candidates) == 0 {
+		return Format{}, false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return less(candidates[i], candidates[j], sortKeys, k.worst)
+	})
+	return candidates[0], true
+}
+
+func matchesFilters(f Format, filters []Filter) bool {
+	for _, filt := range filters {
+		if !matchesFilter(f, filt) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesFilter(f Format, filt Filter) bool {
+	switch filt.Attr {
+	case "vcodec":
+		return matchString(f.VCodec, filt.Op, filt.Value)
+	case "acodec":
+		return matchString(f.ACodec, filt.Op, filt.Value)
+	case "height":
+		return matchNumber(f.Height, filt.Op, filt.Value)
+	case "fps":
+		return matchNumber(f.FPS, filt.Op, filt.Value)
+	case "tbr":
+		return matchNumber(f.TBR, filt.Op, filt.Value)
+	case "abr":
+		return matchNumber(f.ABR, filt.Op, filt.Value)
+	default:
+		return false
+	}
+}
+
+func matchString(have, op, want string) bool {
+	switch op {
+	case "=":
+		return have == want
+	case "^=":
+		return strings.HasPrefix(have, want)
+	default:
+		return false
+	}
+}
+
+func matchNumber(have int, op, want string) bool {
+	wantN, err := strconv.Atoi(want)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case "=":
+		return have == wantN
+	case "<=":
+		return have <= wantN
+	case ">=":
+		return have >= wantN
+	case "<":
+		return have < wantN
+	case ">":
+		return have > wantN
+	default:
+		return false
+	}
+}
+
+// less orders candidate a before b by sortKeys (descending, i.e. "better
+// first"), unless worst is set, in which case the order is reversed.
+func less(a, b Format, sortKeys []string, worst bool) bool {
+	for _, key := range sortKeys {
+		av, bv := scoreFor(a, key), scoreFor(b, key)
+		if av == bv {
+			continue
+		}
+		if worst {
+			return av < bv
+		}
+		return av > bv
+	}
+	return false
+}
+
+func scoreFor(f Format, key string) int {
+	switch key {
+	case "res":
+		return f.Height
+	case "fps":
+		return f.FPS
+	case "tbr":
+		return f.TBR
+	case "abr":
+		return f.ABR
+	default:
+		return 0
+	}
+}
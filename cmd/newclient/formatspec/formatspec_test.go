@@ -0,0 +1,131 @@
+package formatspec
+
+import "testing"
+
+func TestParseAlternativesAndSelectors(t *testing.T) {
+	spec, err := Parse("bv*[height<=1080][vcodec^=avc1]+ba[acodec=opus]/b")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(spec.Alternatives) != 2 {
+		t.Fatalf("got %d alternatives, want 2", len(spec.Alternatives))
+	}
+
+	first := spec.Alternatives[0]
+	if len(first.Selectors) != 2 {
+		t.Fatalf("got %d selectors in first alternative, want 2", len(first.Selectors))
+	}
+	if first.Selectors[0].Kind != "bv*" {
+		t.Errorf("first selector kind = %q, want %q", first.Selectors[0].Kind, "bv*")
+	}
+	if len(first.Selectors[0].Filters) != 2 {
+		t.Fatalf("got %d filters on bv*, want 2", len(first.Selectors[0].Filters))
+	}
+	if f := first.Selectors[0].Filters[0]; f.Attr != "height" || f.Op != "<=" || f.Value != "1080" {
+		t.Errorf("first filter = %+v, want height<=1080", f)
+	}
+	if f := first.Selectors[0].Filters[1]; f.Attr != "vcodec" || f.Op != "^=" || f.Value != "avc1" {
+		t.Errorf("second filter = %+v, want vcodec^=avc1", f)
+	}
+
+	second := spec.Alternatives[1]
+	if len(second.Selectors) != 1 || second.Selectors[0].Kind != "b" {
+		t.Errorf("second alternative = %+v, want a single %q selector", second, "b")
+	}
+}
+
+func TestParseItagSelector(t *testing.T) {
+	spec, err := Parse("137+140")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(spec.Alternatives) != 1 || len(spec.Alternatives[0].Selectors) != 2 {
+		t.Fatalf("got %+v, want one alternative with two bare itag selectors", spec.Alternatives)
+	}
+	if got := spec.Alternatives[0].Selectors[0].Itag; got != 137 {
+		t.Errorf("first itag = %d, want 137", got)
+	}
+	if got := spec.Alternatives[0].Selectors[1].Itag; got != 140 {
+		t.Errorf("second itag = %d, want 140", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{"", "nope", "bv*[height<=100", "bv*[height]"}
+	for _, expr := range cases {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func sampleFormats() []Format {
+	return []Format{
+		{Itag: 137, Height: 1080, FPS: 30, VCodec: "avc1", TBR: 4500, HasVideo: true},
+		{Itag: 248, Height: 1080, FPS: 30, VCodec: "vp9", TBR: 2500, HasVideo: true},
+		{Itag: 399, Height: 2160, FPS: 30, VCodec: "av01", TBR: 9000, HasVideo: true},
+		{Itag: 140, ACodec: "mp4a", ABR: 128, HasAudio: true},
+		{Itag: 251, ACodec: "opus", ABR: 160, HasAudio: true},
+		{Itag: 22, Height: 720, FPS: 30, VCodec: "avc1", ACodec: "mp4a", ABR: 192, TBR: 2000, HasVideo: true, HasAudio: true},
+	}
+}
+
+func TestResolveVideoOnlyPlusAudioOnly(t *testing.T) {
+	spec, err := Parse("bv*[vcodec^=avc1]+ba[acodec=opus]")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	chosen, err := Resolve(spec, sampleFormats(), nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(chosen) != 2 {
+		t.Fatalf("got %d formats, want 2", len(chosen))
+	}
+	if chosen[0].Itag != 137 {
+		t.Errorf("video itag = %d, want 137 (highest-resolution avc1 candidate; itag 22 is also avc1 but 720p)", chosen[0].Itag)
+	}
+	if chosen[1].Itag != 251 {
+		t.Errorf("audio itag = %d, want 251 (only opus candidate)", chosen[1].Itag)
+	}
+}
+
+func TestResolveFallsThroughAlternatives(t *testing.T) {
+	// No progressive (audio+video) format reaches 4K, so "b" can't match and
+	// Resolve should fall through to the adaptive bv*+ba alternative.
+	spec, err := Parse("b[height>=2160]/bv*[height>=2160]+ba")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	chosen, err := Resolve(spec, sampleFormats(), nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(chosen) != 2 || chosen[0].Itag != 399 {
+		t.Fatalf("got %+v, want the adaptive 4K fallback (itag 399 + an audio format)", chosen)
+	}
+}
+
+func TestResolveNoMatch(t *testing.T) {
+	spec, err := Parse("b[height>=8000]")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := Resolve(spec, sampleFormats(), nil); err == nil {
+		t.Error("Resolve succeeded, want error for an unsatisfiable spec")
+	}
+}
+
+func TestResolveWorstPrefersLowestScore(t *testing.T) {
+	spec, err := Parse("wv*")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	chosen, err := Resolve(spec, sampleFormats(), nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(chosen) != 1 || chosen[0].Itag != 22 {
+		t.Fatalf("got %+v, want the lowest-resolution video candidate (itag 22)", chosen)
+	}
+}
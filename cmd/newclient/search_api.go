@@ -0,0 +1,81 @@
+package newclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/option"
+	youtubeapi "google.golang.org/api/youtube/v3"
+)
+
+// APISearcher backs Searcher with the official youtube/v3 Data API, used
+// whenever --api-key is supplied. It trades the no-key InnerTube backend's
+// availability for actual result quality and the type/duration/order filters
+// the API exposes directly.
+type APISearcher struct {
+	APIKey string
+	// HTTPClient, if set, is used instead of the API client's own default -
+	// e.g. to route search.list calls through the same transport.RoundTripper
+	// (proxy rotation, per-host rate limiting) the rest of GoYoutube uses.
+	HTTPClient *http.Client
+}
+
+// Search implements Searcher against the youtube/v3 "search.list" endpoint.
+func (s *APISearcher) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	clientOpts := []option.ClientOption{option.WithAPIKey(s.APIKey)}
+	if s.HTTPClient != nil {
+		clientOpts = append(clientOpts, option.WithHTTPClient(s.HTTPClient))
+	}
+	svc, err := youtubeapi.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("youtube/v3 client init failed: %w", err)
+	}
+
+	call := svc.Search.List([]string{"id", "snippet"}).Q(query)
+
+	searchType := opts.Type
+	if searchType == "" {
+		searchType = "video,playlist"
+	}
+	call = call.Type(searchType)
+
+	if opts.Duration != "" {
+		call = call.VideoDuration(opts.Duration)
+	}
+	if opts.Order != "" {
+		call = call.Order(opts.Order)
+	}
+
+	limit := int64(opts.Limit)
+	if limit <= 0 || limit > 50 {
+		limit = 50
+	}
+	call = call.MaxResults(limit)
+
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("search.list failed: %w", err)
+	}
+
+	var out []SearchResult
+	for _, item := range resp.Items {
+		switch {
+		case item.Id.VideoId != "":
+			out = append(out, SearchResult{
+				ID:     item.Id.VideoId,
+				Type:   SearchResultVideo,
+				Title:  item.Snippet.Title,
+				Author: item.Snippet.ChannelTitle,
+			})
+		case item.Id.PlaylistId != "":
+			out = append(out, SearchResult{
+				ID:     item.Id.PlaylistId,
+				Type:   SearchResultPlaylist,
+				Title:  item.Snippet.Title,
+				Author: item.Snippet.ChannelTitle,
+			})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vbauerster/mpb/v7"
+	"github.com/vbauerster/mpb/v7/decor"
+
+	sinkpkg "github.com/AD0791/GoYoutube/cmd/sink"
+)
+
+const (
+	rangeMaxRetries  = 5
+	rangeInitBackoff = 500 * time.Millisecond
+)
+
+// rangedDownload splits [0, contentLength) into numChunks byte ranges and
+// fetches each with its own "Range: bytes=start-end" GET, writing straight
+// into dst via WriteAt from worker goroutines. This beats a single io.Copy
+// over one socket on playlist-scale runs, and dst being a sink.Sink rather
+// than an *os.File means the same engine drives both local-file and S3
+// targets. bar, if non-nil, is advanced by every chunk as bytes land.
+func rangedDownload(httpClient *http.Client, url string, dst sinkpkg.Sink, contentLength int64, numChunks int, bar *mpb.Bar) error {
+	if contentLength <= 0 || numChunks <= 1 {
+		return singleStreamDownload(httpClient, url, dst, bar)
+	}
+
+	chunkSize := contentLength / int64(numChunks)
+	if chunkSize == 0 {
+		chunkSize = contentLength
+		numChunks = 1
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, numChunks)
+
+	for i := 0; i < numChunks; i++ {
+		start := int64(i) * chunkSize
+		end := start + chunkSize - 1
+		if i == numChunks-1 {
+			end = contentLength - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := downloadChunkWithRetry(httpClient, url, dst, start, end, bar); err != nil {
+				errCh <- err
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadChunkWithRetry fetches [start, end] and writes it at offset start,
+// retrying with exponential backoff on transient failures.
+func downloadChunkWithRetry(httpClient *http.Client, url string, dst sinkpkg.Sink, start, end int64, bar *mpb.Bar) error {
+	backoff := rangeInitBackoff
+	var lastErr error
+
+	for attempt := 0; attempt < rangeMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if err := downloadChunk(httpClient, url, dst, start, end, bar); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("chunk [%d-%d] failed after %d attempts: %w", start, end, rangeMaxRetries, lastErr)
+}
+
+func downloadChunk(httpClient *http.Client, url string, dst sinkpkg.Sink, start, end int64, bar *mpb.Bar) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d for range bytes=%d-%d", resp.StatusCode, start, end)
+	}
+
+	n, err := io.Copy(io.NewOffsetWriter(dst, start), resp.Body)
+	if err != nil {
+		return err
+	}
+	if bar != nil {
+		bar.IncrBy(int(n))
+	}
+	return nil
+}
+
+// singleStreamDownload is the fallback when the server didn't report a
+// ContentLength (so we can't pre-allocate chunks) or --chunks<=1.
+func singleStreamDownload(httpClient *http.Client, url string, dst sinkpkg.Sink, bar *mpb.Bar) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	w := io.NewOffsetWriter(dst, 0)
+	var body io.Reader = resp.Body
+	if bar != nil {
+		body = bar.ProxyReader(resp.Body)
+	}
+
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// newProgressContainer builds the mpb container used by runDownload: an
+// overall bar tracking playlist completion plus one bar per in-flight video.
+func newProgressContainer() *mpb.Progress {
+	return mpb.New(mpb.WithWidth(40))
+}
+
+// addOverallBar adds the playlist-wide progress bar (counted in items, not bytes).
+func addOverallBar(p *mpb.Progress, total int) *mpb.Bar {
+	return p.AddBar(int64(total),
+		mpb.PrependDecorators(decor.Name("playlist", decor.WC{W: 10})),
+		mpb.AppendDecorators(decor.CountersNoUnit("%d / %d"), decor.Percentage()),
+	)
+}
+
+// addVideoBar adds a per-video byte progress bar with speed and ETA.
+func addVideoBar(p *mpb.Progress, name string, total int64) *mpb.Bar {
+	return p.AddBar(total,
+		mpb.PrependDecorators(decor.Name(name, decor.WC{W: len(name) + 1, C: decor.DidentRight})),
+		mpb.AppendDecorators(
+			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 30),
+			decor.OnComplete(decor.EwmaETA(decor.ET_STYLE_GO, 30), "done"),
+			decor.Percentage(),
+		),
+	)
+}
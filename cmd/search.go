@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	newclient "github.com/AD0791/GoYoutube/cmd/newclient"
+	"github.com/AD0791/GoYoutube/cmd/transport"
+)
+
+var (
+	searchAPIKey      string
+	searchType        string
+	searchDuration    string
+	searchOrder       string
+	searchLimit       int
+	searchDownloadAll bool
+)
+
+// searchCmd is our Cobra subcommand ("GoYoutube search <query>")
+var searchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search YouTube for videos and playlists",
+	Long: `Search YouTube and print a ranked list of video/playlist matches.
+Without --api-key this uses the same no-key InnerTube endpoint the web
+client does; with --api-key it uses the youtube/v3 Data API instead, which
+supports --type/--duration/--order filtering.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSearch(strings.Join(args, " "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().StringVar(&searchAPIKey, "api-key", "", "youtube/v3 Data API key; switches the search backend from InnerTube to the official API")
+	searchCmd.Flags().StringVar(&searchType, "type", "", "Result type filter (video|playlist|channel); only honored with --api-key")
+	searchCmd.Flags().StringVar(&searchDuration, "duration", "", "Video duration filter (short|medium|long); only honored with --api-key")
+	searchCmd.Flags().StringVar(&searchOrder, "order", "", "Result order (date|rating|viewCount); only honored with --api-key")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "Maximum number of results to print/download")
+	searchCmd.Flags().BoolVar(&searchDownloadAll, "download-all", false, "Pipe every result (up to --limit) into the download path")
+	searchCmd.Flags().StringVar(&proxiesFile, "proxies", "", "Path to a newline-separated list of SOCKS5/HTTP proxies to rotate through (defaults to $GOYT_PROXIES)")
+	searchCmd.Flags().Float64Var(&rps, "rps", 0, "Requests/sec rate limit per destination host (googlevideo.com, youtube.com); 0 disables limiting")
+}
+
+func runSearch(query string) error {
+	rt, err := transport.New(proxiesFile, rps)
+	if err != nil {
+		return fmt.Errorf("transport.New failed: %w", err)
+	}
+	httpClient := &http.Client{Transport: rt}
+
+	var searcher newclient.Searcher
+	if searchAPIKey != "" {
+		searcher = &newclient.APISearcher{APIKey: searchAPIKey, HTTPClient: httpClient}
+	} else {
+		searcher = &newclient.InnerTubeSearcher{HTTPClient: httpClient}
+	}
+
+	results, err := searcher.Search(context.Background(), query, newclient.SearchOptions{
+		Type:     searchType,
+		Duration: searchDuration,
+		Order:    searchOrder,
+		Limit:    searchLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	for i, r := range results {
+		fmt.Printf("%2d. [%s] %s - %s (%s)\n", i+1, r.Type, r.Title, r.Author, r.ID)
+	}
+
+	if !searchDownloadAll {
+		return nil
+	}
+
+	baseOutputDir := outputDir
+	for _, r := range results {
+		var videoURL string
+		switch r.Type {
+		case newclient.SearchResultVideo:
+			videoURL = "https://www.youtube.com/watch?v=" + r.ID
+		case newclient.SearchResultPlaylist:
+			videoURL = "https://www.youtube.com/playlist?list=" + r.ID
+		default:
+			continue
+		}
+
+		inputURL = videoURL
+		outputDir = filepath.Join(baseOutputDir, sanitizeFilename(r.Title))
+		if err := runDownload(); err != nil {
+			fmt.Printf("download failed for %s: %v\n", r.Title, err)
+		}
+	}
+	return nil
+}
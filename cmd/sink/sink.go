@@ -0,0 +1,356 @@
+// Package sink abstracts "where downloaded bytes land" so downloadSingleVideo
+// doesn't have to hard-code os.Create. FileSink covers the local-disk case
+// GoYoutube always supported; S3Sink lets -o accept an "s3://bucket/prefix/"
+// target and streams straight into a multipart upload instead of staging to
+// disk first.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Sink is anything rangedDownload can write pre-allocated byte ranges into.
+// FileSink and S3Sink both satisfy it; NullSink exists for tests that only
+// care about the bytes written, not where they go.
+type Sink interface {
+	io.WriterAt
+	// Close finalizes the sink - for S3Sink this completes the multipart
+	// upload, so it must be called exactly once after every chunk lands.
+	Close() error
+}
+
+// FileSink wraps a pre-allocated local file, the same as the plain
+// os.Create + WriteAt path GoYoutube used before any sink existed.
+type FileSink struct {
+	f *os.File
+}
+
+// NewFileSink creates (or truncates) path, pre-sized to size bytes so
+// concurrent WriteAt calls from chunk workers never race over growing it.
+func NewFileSink(path string, size int64) (*FileSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (s *FileSink) WriteAt(p []byte, off int64) (int, error) { return s.f.WriteAt(p, off) }
+func (s *FileSink) Close() error                             { return s.f.Close() }
+
+// NullSink discards every write; used by tests exercising the download path
+// without touching disk or a network target.
+type NullSink struct{}
+
+func (NullSink) WriteAt(p []byte, off int64) (int, error) { return len(p), nil }
+func (NullSink) Close() error                             { return nil }
+
+// Aborter is implemented by sinks that can cancel whatever they've staged so
+// far instead of finalizing it - currently only S3Sink, since an aborted
+// local file just gets removed by the caller directly.
+type Aborter interface {
+	Abort() error
+}
+
+// Discard finalizes a sink on a failed download: S3Sink aborts the multipart
+// upload so a partial object is never completed and the upload stops being
+// billed, while FileSink/NullSink just Close as normal since there's nothing
+// server-side to cancel. Callers on the success path must still call Close
+// directly instead of Discard.
+func Discard(s Sink) error {
+	if a, ok := s.(Aborter); ok {
+		return a.Abort()
+	}
+	return s.Close()
+}
+
+// IsS3URL reports whether target looks like "s3://bucket/key".
+func IsS3URL(target string) bool {
+	return strings.HasPrefix(target, "s3://")
+}
+
+// ParseS3URL splits "s3://bucket/prefix/video.mp4" into bucket and key.
+func ParseS3URL(target string) (bucket, key string, err error) {
+	rest := strings.TrimPrefix(target, "s3://")
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid s3 url %q, expected s3://bucket/key", target)
+	}
+	return parts[0], parts[1], nil
+}
+
+// s3part accumulates bytes for one part number until it's full (or flagged
+// as the final, possibly short, part), at which point it's uploaded.
+type s3part struct {
+	buf    []byte
+	filled int64
+}
+
+// S3Sink buffers WriteAt calls into partSize-aligned buffers and fires one
+// UploadPart per completed part, so the object is built via a multipart
+// upload instead of staging the whole file on local disk first. This relies
+// on rangedDownload handing it whole, non-overlapping byte ranges - which is
+// exactly what its chunk workers already produce.
+type S3Sink struct {
+	ctx       context.Context
+	client    *s3.Client
+	bucket    string
+	key       string
+	uploadID  string
+	partSize  int64
+	totalSize int64
+
+	mu    sync.Mutex
+	parts map[int64]*s3part
+	done  []types.CompletedPart
+}
+
+// NewS3Sink starts a multipart upload for bucket/key. partSize must be >=
+// 5 MiB per the S3 API (except for the final part). region, if empty, is
+// resolved from the environment the same way the AWS SDK default chain does.
+// totalSize is the full object size, used to size the last (possibly short)
+// part correctly.
+func NewS3Sink(ctx context.Context, bucket, key string, partSize, totalSize int64, region string) (*S3Sink, error) {
+	if partSize <= 0 {
+		partSize = 5 * 1024 * 1024
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(cfg)
+
+	out, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CreateMultipartUpload: %w", err)
+	}
+
+	return &S3Sink{
+		ctx:       ctx,
+		client:    client,
+		bucket:    bucket,
+		key:       key,
+		uploadID:  aws.ToString(out.UploadId),
+		partSize:  partSize,
+		totalSize: totalSize,
+		parts:     make(map[int64]*s3part),
+	}, nil
+}
+
+// WriteAt copies p into the part(s) it overlaps, uploading each part as soon
+// as it's completely filled.
+func (s *S3Sink) WriteAt(p []byte, off int64) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		partNumber := off/s.partSize + 1
+		partStart := (partNumber - 1) * s.partSize
+		partLen := s.partSizeFor(partNumber)
+
+		localOff := off - partStart
+		n := copy(s.bufferFor(partNumber, partLen)[localOff:], p)
+
+		if err := s.noteFilled(partNumber, int64(n), partLen); err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+		off += int64(n)
+		written += n
+	}
+	return written, nil
+}
+
+// partSizeFor returns how many bytes partNumber is expected to hold. When
+// totalSize is known this sizes the final part exactly so noteFilled flushes
+// it like any other; when totalSize is unknown (e.g. an adaptive format
+// whose Format.ContentLength didn't parse), every part is provisionally
+// sized at partSize and the true final part - necessarily short - is instead
+// caught and flushed by flushFinalPart in Close.
+func (s *S3Sink) partSizeFor(partNumber int64) int64 {
+	if s.totalSize <= 0 {
+		return s.partSize
+	}
+	partStart := (partNumber - 1) * s.partSize
+	remaining := s.totalSize - partStart
+	if remaining < s.partSize {
+		return remaining
+	}
+	return s.partSize
+}
+
+// flushFinalPart handles the unknown-totalSize case: the true last part
+// never reaches partSizeFor's provisional full size, so it's still sitting
+// in s.parts when Close is called. Exactly one such part is expected - the
+// highest part number, holding whatever was left over - and it's uploaded
+// with its actual filled length. Any other still-buffered part indicates a
+// real gap (a part that was never completed), so Close fails loud instead of
+// silently completing an object with a hole in it.
+func (s *S3Sink) flushFinalPart() error {
+	s.mu.Lock()
+	pending := make([]int64, 0, len(s.parts))
+	for pn, part := range s.parts {
+		if part.filled > 0 {
+			pending = append(pending, pn)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+
+	if len(pending) > 1 {
+		return fmt.Errorf("S3Sink.Close: %d parts never finished uploading (parts %v) - refusing to complete an object with a gap", len(pending), pending[:len(pending)-1])
+	}
+
+	lastPart := pending[0]
+	s.mu.Lock()
+	part := s.parts[lastPart]
+	data := part.buf[:part.filled]
+	s.mu.Unlock()
+
+	if err := s.uploadPart(lastPart, data); err != nil {
+		return fmt.Errorf("flushing final part %d: %w", lastPart, err)
+	}
+	return nil
+}
+
+func (s *S3Sink) bufferFor(partNumber, partLen int64) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	part, ok := s.parts[partNumber]
+	if !ok {
+		part = &s3part{buf: make([]byte, partLen)}
+		s.parts[partNumber] = part
+	}
+	return part.buf
+}
+
+// noteFilled records n more bytes landing in partNumber's buffer and flushes
+// it via UploadPart once it reaches partLen.
+func (s *S3Sink) noteFilled(partNumber, n, partLen int64) error {
+	s.mu.Lock()
+	part := s.parts[partNumber]
+	part.filled += n
+	full := part.filled >= partLen
+	buf := part.buf
+	s.mu.Unlock()
+
+	if !full {
+		return nil
+	}
+	return s.uploadPart(partNumber, buf)
+}
+
+func (s *S3Sink) uploadPart(partNumber int64, data []byte) error {
+	resp, err := s.client.UploadPart(s.ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(s.key),
+		UploadId:   aws.String(s.uploadID),
+		PartNumber: int32(partNumber),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("UploadPart %d: %w", partNumber, err)
+	}
+
+	s.mu.Lock()
+	s.done = append(s.done, types.CompletedPart{
+		ETag:       resp.ETag,
+		PartNumber: int32(partNumber),
+	})
+	delete(s.parts, partNumber)
+	s.mu.Unlock()
+	return nil
+}
+
+// Close flushes any still-buffered final part, then completes the multipart
+// upload, stitching together every part uploaded so far. Parts must be
+// submitted to CompleteMultipartUpload in ascending PartNumber order.
+func (s *S3Sink) Close() error {
+	if err := s.flushFinalPart(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	sort.Slice(s.done, func(i, j int) bool {
+		return s.done[i].PartNumber < s.done[j].PartNumber
+	})
+	done := s.done
+	s.mu.Unlock()
+
+	_, err := s.client.CompleteMultipartUpload(s.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s.key),
+		UploadId: aws.String(s.uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: done,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("CompleteMultipartUpload: %w", err)
+	}
+	return nil
+}
+
+// Abort cancels the multipart upload, e.g. after a chunk download fails past
+// its retry budget, so S3 doesn't keep billing for the orphaned parts.
+func (s *S3Sink) Abort() error {
+	_, err := s.client.AbortMultipartUpload(s.ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s.key),
+		UploadId: aws.String(s.uploadID),
+	})
+	return err
+}
+
+// Exists issues a HeadObject, used in place of os.Stat for --skip-existing
+// against an s3:// target.
+func Exists(ctx context.Context, client *s3.Client, bucket, key string) bool {
+	_, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err == nil
+}
+
+// NewS3Client resolves AWS config (optionally pinned to region) and returns a
+// bare *s3.Client for use with Exists before a sink even exists.
+func NewS3Client(ctx context.Context, region string) (*s3.Client, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	return s3.NewFromConfig(cfg), nil
+}
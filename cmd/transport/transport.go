@@ -0,0 +1,194 @@
+// Package transport provides the pluggable http.RoundTripper GoYoutube wires
+// into both the embedded youtube.Client and the bare InnerTube POST calls in
+// newclient, so large playlists don't hammer YouTube from a single IP and
+// get 429'd.
+package transport
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultUserAgent     = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	defaultClientName    = "1" // WEB, per InnerTube's numeric client IDs
+	defaultClientVersion = "2.20210721.00.00"
+
+	proxyCooldown  = 2 * time.Minute
+	maxRetries     = 4
+	initialBackoff = 300 * time.Millisecond
+)
+
+// RoundTripper rotates across a pool of proxies - failing over on
+// 403/429/5xx with exponential backoff and a cooldown for the proxy that
+// just failed - enforces a token-bucket rate limit per destination host, and
+// injects the User-Agent/X-YouTube-Client-* headers the raw InnerTube POST
+// in newclient.GetPlaylistPageToken doesn't set on its own.
+type RoundTripper struct {
+	rps float64
+
+	mu       sync.Mutex
+	proxies  []*proxyState
+	next     int
+	limiters map[string]*rate.Limiter
+}
+
+type proxyState struct {
+	url       *url.URL // nil means "direct, no proxy"
+	transport *http.Transport
+	badUntil  time.Time
+}
+
+// New builds a RoundTripper. proxyFile, if non-empty, is a newline-separated
+// list of SOCKS5/HTTP proxy URLs; if empty it falls back to $GOYT_PROXIES,
+// then to a single direct (no-proxy) entry. rps bounds requests/sec to each
+// destination host (googlevideo.com, youtube.com, ...); 0 disables limiting.
+func New(proxyFile string, rps float64) (*RoundTripper, error) {
+	if proxyFile == "" {
+		proxyFile = os.Getenv("GOYT_PROXIES")
+	}
+
+	var proxies []*proxyState
+	if proxyFile != "" {
+		urls, err := loadProxies(proxyFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range urls {
+			proxies = append(proxies, &proxyState{url: u, transport: &http.Transport{Proxy: http.ProxyURL(u)}})
+		}
+	}
+	if len(proxies) == 0 {
+		proxies = append(proxies, &proxyState{transport: &http.Transport{}})
+	}
+
+	return &RoundTripper{
+		rps:      rps,
+		proxies:  proxies,
+		limiters: make(map[string]*rate.Limiter),
+	}, nil
+}
+
+func loadProxies(path string) ([]*url.URL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening proxy list %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var urls []*url.URL
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy %q: %w", line, err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, scanner.Err()
+}
+
+// limiterFor lazily creates the token bucket for host, bursting one request
+// above the steady rate so a cold start doesn't immediately block.
+func (t *RoundTripper) limiterFor(host string) *rate.Limiter {
+	if t.rps <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(t.rps), int(t.rps)+1)
+		t.limiters[host] = l
+	}
+	return l
+}
+
+// pickProxy returns the next proxy in round-robin order that isn't cooling
+// down; if every proxy is currently cooling down it returns the next one in
+// line anyway rather than blocking.
+func (t *RoundTripper) pickProxy() *proxyState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(t.proxies); i++ {
+		p := t.proxies[t.next%len(t.proxies)]
+		t.next++
+		if p.badUntil.Before(now) {
+			return p
+		}
+	}
+	return t.proxies[t.next%len(t.proxies)]
+}
+
+func (t *RoundTripper) markBad(p *proxyState) {
+	t.mu.Lock()
+	p.badUntil = time.Now().Add(proxyCooldown)
+	t.mu.Unlock()
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", defaultUserAgent)
+	}
+	if req.Header.Get("X-YouTube-Client-Name") == "" {
+		req.Header.Set("X-YouTube-Client-Name", defaultClientName)
+	}
+	if req.Header.Get("X-YouTube-Client-Version") == "" {
+		req.Header.Set("X-YouTube-Client-Version", defaultClientVersion)
+	}
+
+	if l := t.limiterFor(req.URL.Host); l != nil {
+		if err := l.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	backoff := initialBackoff
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		attemptReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewinding request body for retry: %w", err)
+			}
+			attemptReq.Body = body
+		}
+
+		p := t.pickProxy()
+		resp, err := p.transport.RoundTrip(attemptReq)
+		if err != nil {
+			lastErr = err
+			t.markBad(p)
+			continue
+		}
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("status %d from proxy %v", resp.StatusCode, p.url)
+			t.markBad(p)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("all proxies exhausted after %d attempts: %w", maxRetries+1, lastErr)
+}